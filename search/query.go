@@ -1,13 +1,47 @@
 package search
 
 import (
+	"errors"
 	"fmt"
 	"github.com/balzaczyy/golucene/index"
 )
 
+// MaxRewriteDepth bounds how many levels deep a Query.Rewrite call tree may
+// recurse before failing with ErrQueryTooDeep. Real Query implementations
+// (BooleanQuery, MultiTermQuery, SpanQuery, ...) rewrite by recursively
+// rewriting their children, and a pathological or adversarially crafted
+// query can drive that recursion into stack exhaustion; this bounds it.
+var MaxRewriteDepth = 1024
+
+// ErrQueryTooDeep is returned by Rewrite once a query tree's nesting
+// exceeds RewriteContext.MaxDepth.
+var ErrQueryTooDeep = errors.New("query tree exceeds max rewrite depth")
+
+// RewriteContext threads the current recursion depth through a
+// Query.Rewrite call tree so each level can enforce MaxDepth.
+type RewriteContext struct {
+	Depth    int
+	MaxDepth int
+}
+
+// NewRewriteContext returns a RewriteContext starting at depth 0, using
+// MaxRewriteDepth as its limit.
+func NewRewriteContext() *RewriteContext {
+	return &RewriteContext{MaxDepth: MaxRewriteDepth}
+}
+
+// child returns the context a Rewrite implementation should pass to its
+// children's Rewrite calls, failing once that would exceed MaxDepth.
+func (ctx *RewriteContext) child() (*RewriteContext, error) {
+	if ctx.Depth+1 > ctx.MaxDepth {
+		return nil, ErrQueryTooDeep
+	}
+	return &RewriteContext{Depth: ctx.Depth + 1, MaxDepth: ctx.MaxDepth}, nil
+}
+
 type Query interface {
 	CreateWeight(ss IndexSearcher) Weight
-	Rewrite(r index.IndexReader) Query
+	Rewrite(r index.IndexReader, ctx *RewriteContext) (Query, error)
 }
 
 type AbstractQuery struct {
@@ -22,6 +56,163 @@ func (q *AbstractQuery) CreateWeight(ss IndexSearcher) Weight {
 	panic(fmt.Sprintf("Query %v does not implement createWeight", q))
 }
 
-func (q *AbstractQuery) Rewrite(r index.IndexReader) Query {
-	return q
+func (q *AbstractQuery) Rewrite(r index.IndexReader, ctx *RewriteContext) (Query, error) {
+	return q, nil
+}
+
+// Clauses is implemented by queries, such as BooleanQuery, that rewrite by
+// recursing into child queries. RewriteIterative uses it to recognize the
+// common Boolean-tree case and drive it with an explicit work-stack
+// instead of native recursion.
+type Clauses interface {
+	Query
+	GetClauses() []Query
+	WithClauses(clauses []Query) Query
+}
+
+// rewriteFrame is one Clauses node's place on RewriteIterative's explicit
+// stack: which of its clauses have already been pushed for rewriting, and
+// at what depth it sits.
+type rewriteFrame struct {
+	query        Query
+	isComposite  bool
+	clauses      []Query
+	childrenDone int
+	depth        int
+}
+
+func newRewriteFrame(q Query, depth int) *rewriteFrame {
+	frame := &rewriteFrame{query: q, depth: depth}
+	if c, ok := q.(Clauses); ok {
+		frame.isComposite = true
+		frame.clauses = c.GetClauses()
+	}
+	return frame
+}
+
+// RewriteIterative rewrites q against r. Wherever q (or one of its
+// descendants) implements Clauses, its children are rewritten via an
+// explicit stack rather than recursion, so a well-formed but very wide
+// query tree still succeeds while one nested beyond MaxRewriteDepth fails
+// fast with ErrQueryTooDeep instead of exhausting the Go call stack.
+//
+// A composite (Clauses) node is never handed back to its own Rewrite: that
+// method recurses into its clauses itself (see BooleanQuery.Rewrite), and
+// calling it here - after the stack above has already rewritten those same
+// clauses - would re-descend the subtree natively and defeat the point of
+// driving the traversal iteratively. Instead the rewritten clauses are
+// spliced back in directly via WithClauses. Only leaves (non-composite
+// nodes) have their Rewrite called, since that's where per-node rewrite
+// logic (e.g. query expansion) actually lives.
+//
+// A composite node whose rewritten children are all identical to its
+// original clauses is returned unchanged rather than rebuilt via
+// WithClauses, matching the identity-preservation BooleanQuery.Rewrite's
+// native path already guarantees (see its own `if !changed { return q,
+// nil }`). Callers that drive a rewrite to a fixed point the standard way
+// - `for rewritten != query { rewritten, query = ..., rewritten }` - rely
+// on that to terminate.
+func RewriteIterative(q Query, r index.IndexReader) (Query, error) {
+	stack := []*rewriteFrame{newRewriteFrame(q, 0)}
+	var results []Query
+
+	for len(stack) > 0 {
+		frame := stack[len(stack)-1]
+
+		if frame.childrenDone < len(frame.clauses) {
+			childDepth := frame.depth + 1
+			if childDepth > MaxRewriteDepth {
+				return nil, ErrQueryTooDeep
+			}
+			stack = append(stack, newRewriteFrame(frame.clauses[frame.childrenDone], childDepth))
+			frame.childrenDone++
+			continue
+		}
+
+		stack = stack[:len(stack)-1]
+
+		if !frame.isComposite {
+			ctx := &RewriteContext{Depth: frame.depth, MaxDepth: MaxRewriteDepth}
+			rewritten, err := frame.query.Rewrite(r, ctx)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, rewritten)
+			continue
+		}
+
+		n := len(frame.clauses)
+		children := append([]Query{}, results[len(results)-n:]...)
+		results = results[:len(results)-n]
+
+		changed := false
+		for i, child := range children {
+			if child != frame.clauses[i] {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			results = append(results, frame.query)
+			continue
+		}
+		results = append(results, frame.query.(Clauses).WithClauses(children))
+	}
+
+	return results[0], nil
+}
+
+// BooleanQuery combines clauses conjunctively/disjunctively. It is the
+// common case RewriteIterative targets: rewriting it means rewriting every
+// clause, which for a pathological tree is exactly the recursion
+// MaxRewriteDepth guards against.
+type BooleanQuery struct {
+	*AbstractQuery
+	clauses []Query
+}
+
+func NewBooleanQuery() *BooleanQuery {
+	return &BooleanQuery{AbstractQuery: NewAbstractQuery()}
+}
+
+func (q *BooleanQuery) Add(clause Query) {
+	q.clauses = append(q.clauses, clause)
+}
+
+func (q *BooleanQuery) GetClauses() []Query {
+	return q.clauses
+}
+
+func (q *BooleanQuery) WithClauses(clauses []Query) Query {
+	return &BooleanQuery{AbstractQuery: q.AbstractQuery, clauses: clauses}
+}
+
+// Rewrite recursively rewrites every clause, the natural (and, called
+// directly rather than through RewriteIterative, native-stack-recursive)
+// implementation real Lucene subclasses use. ctx.child() enforces
+// MaxRewriteDepth on that recursion, so a caller that invokes Rewrite
+// directly - bypassing RewriteIterative - still fails fast on a
+// pathologically deep tree instead of exhausting the stack.
+func (q *BooleanQuery) Rewrite(r index.IndexReader, ctx *RewriteContext) (Query, error) {
+	childCtx, err := ctx.child()
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten := make([]Query, len(q.clauses))
+	changed := false
+	for i, clause := range q.clauses {
+		rewrittenClause, err := clause.Rewrite(r, childCtx)
+		if err != nil {
+			return nil, err
+		}
+		rewritten[i] = rewrittenClause
+		if rewrittenClause != clause {
+			changed = true
+		}
+	}
+	if !changed {
+		return q, nil
+	}
+	return q.WithClauses(rewritten), nil
 }
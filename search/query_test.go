@@ -0,0 +1,97 @@
+package search
+
+import "testing"
+
+func deepBooleanQuery(depth int) Query {
+	q := NewBooleanQuery()
+	if depth > 0 {
+		q.Add(deepBooleanQuery(depth - 1))
+	}
+	return q
+}
+
+func wideBooleanQuery(width int) Query {
+	q := NewBooleanQuery()
+	for i := 0; i < width; i++ {
+		q.Add(NewAbstractQuery())
+	}
+	return q
+}
+
+func TestRewriteIterativeWideTreeSucceeds(t *testing.T) {
+	q := wideBooleanQuery(10000)
+	if _, err := RewriteIterative(q, nil); err != nil {
+		t.Fatalf("expected wide tree to rewrite successfully, got %v", err)
+	}
+}
+
+func TestRewriteIterativeModestTreeSucceeds(t *testing.T) {
+	q := deepBooleanQuery(MaxRewriteDepth - 10)
+	if _, err := RewriteIterative(q, nil); err != nil {
+		t.Fatalf("expected tree within MaxRewriteDepth to succeed, got %v", err)
+	}
+}
+
+func TestRewriteIterativeDeepTreeFails(t *testing.T) {
+	q := deepBooleanQuery(MaxRewriteDepth + 10)
+	if _, err := RewriteIterative(q, nil); err != ErrQueryTooDeep {
+		t.Fatalf("expected ErrQueryTooDeep, got %v", err)
+	}
+}
+
+// TestDirectRewriteEnforcesMaxDepth guards the non-iterative path: a
+// caller that invokes Query.Rewrite directly, without going through
+// RewriteIterative, must still get ErrQueryTooDeep on a too-deep tree
+// rather than no protection at all.
+func TestDirectRewriteEnforcesMaxDepth(t *testing.T) {
+	q := deepBooleanQuery(MaxRewriteDepth + 10)
+	if _, err := q.Rewrite(nil, NewRewriteContext()); err != ErrQueryTooDeep {
+		t.Fatalf("expected ErrQueryTooDeep from direct Rewrite, got %v", err)
+	}
+}
+
+func TestDirectRewriteSucceedsWithinMaxDepth(t *testing.T) {
+	q := deepBooleanQuery(MaxRewriteDepth - 10)
+	if _, err := q.Rewrite(nil, NewRewriteContext()); err != nil {
+		t.Fatalf("expected tree within MaxRewriteDepth to succeed, got %v", err)
+	}
+}
+
+// TestRewriteIterativeReturnsSameIdentityWhenNoChildChanged guards the
+// invariant BooleanQuery.Rewrite's native path already relies on (see its
+// own `if !changed { return q, nil }`): a caller driving Rewrite to a fixed
+// point via `for rewritten != query { ... }` needs unchanged composite
+// nodes to compare equal across a pass, not merely equal in value. None of
+// AbstractQuery's leaves rewrite into anything new, so the whole tree,
+// including every composite in it, must come back as the identical Query.
+func TestRewriteIterativeReturnsSameIdentityWhenNoChildChanged(t *testing.T) {
+	q := wideBooleanQuery(10)
+	rewritten, err := RewriteIterative(q, nil)
+	if err != nil {
+		t.Fatalf("expected rewrite to succeed, got %v", err)
+	}
+	if rewritten != q {
+		t.Fatalf("expected RewriteIterative to return the original query when no clause changed, got a new identity")
+	}
+}
+
+// TestRewriteIterativeHandlesDepthFarBeyondNativeRecursionBudget shows the
+// iterative driver's stack usage is decoupled from tree depth: with
+// MaxRewriteDepth raised well past what BooleanQuery.Rewrite's native
+// recursion (exercised by TestDirectRewriteEnforcesMaxDepth above) could
+// safely reach, RewriteIterative still succeeds because it drives Clauses
+// nodes through an explicit stack on the heap rather than the Go call
+// stack. We don't invoke the direct, natively-recursive Rewrite at this
+// depth - it's exactly the stack exhaustion this feature exists to avoid.
+func TestRewriteIterativeHandlesDepthFarBeyondNativeRecursionBudget(t *testing.T) {
+	const veryDeep = 200000
+
+	original := MaxRewriteDepth
+	MaxRewriteDepth = veryDeep + 1
+	defer func() { MaxRewriteDepth = original }()
+
+	q := deepBooleanQuery(veryDeep)
+	if _, err := RewriteIterative(q, nil); err != nil {
+		t.Fatalf("expected very deep tree to rewrite successfully via the iterative driver, got %v", err)
+	}
+}
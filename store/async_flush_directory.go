@@ -0,0 +1,221 @@
+package store
+
+import "sync"
+
+// DEFAULT_ASYNC_WRITERS is the default size of AsyncFlushDirectory's
+// background writer pool.
+const DEFAULT_ASYNC_WRITERS = 4
+
+// asyncWriteTask is one filled buffer waiting to be drained to disk, so
+// merges (which already special-case IO_CONTEXT_TYPE_MERGE) can overlap
+// disk I/O with CPU-bound posting-list construction instead of blocking on
+// each flush.
+type asyncWriteTask struct {
+	name   string
+	buf    []byte
+	offset int64
+}
+
+// AsyncFlushDirectory wraps a Directory, running a bounded pool of
+// background writer goroutines that drain filled buffers to the wrapped
+// Directory while the caller keeps filling the next one. Writes for a given
+// file are always routed to the same goroutine, so per-file ordering is
+// preserved even though the pool itself is shared across files.
+type AsyncFlushDirectory struct {
+	*Directory
+	wrapped    *Directory
+	numWriters int
+	queues     []chan asyncWriteTask
+	bufferPool sync.Pool
+	wg         sync.WaitGroup
+
+	mu      sync.Mutex
+	drained *sync.Cond
+	pending map[string]int
+	outputs map[string]*IndexOutput
+
+	errMu    sync.Mutex
+	firstErr error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewAsyncFlushDirectory wraps wrapped with DEFAULT_ASYNC_WRITERS background
+// writer goroutines.
+func NewAsyncFlushDirectory(wrapped *Directory) *AsyncFlushDirectory {
+	return NewAsyncFlushDirectoryN(wrapped, DEFAULT_ASYNC_WRITERS)
+}
+
+// NewAsyncFlushDirectoryN wraps wrapped with numWriters background writer
+// goroutines.
+func NewAsyncFlushDirectoryN(wrapped *Directory, numWriters int) *AsyncFlushDirectory {
+	if numWriters <= 0 {
+		panic("numWriters must be greater than 0")
+	}
+	d := &AsyncFlushDirectory{
+		wrapped:    wrapped,
+		numWriters: numWriters,
+		queues:     make([]chan asyncWriteTask, numWriters),
+		pending:    make(map[string]int),
+		outputs:    make(map[string]*IndexOutput),
+	}
+	d.drained = sync.NewCond(&d.mu)
+	d.bufferPool.New = func() interface{} { return make([]byte, 0, DEFAULT_OUTPUT_BUFFER_SIZE) }
+	for i := 0; i < numWriters; i++ {
+		q := make(chan asyncWriteTask, numWriters*4)
+		d.queues[i] = q
+		d.wg.Add(1)
+		go d.drain(q)
+	}
+
+	super := Directory{
+		isOpen:       true,
+		ListAll:      wrapped.ListAll,
+		OpenInput:    wrapped.OpenInput,
+		LockID:       wrapped.LockID,
+		CreateOutput: d.createOutput,
+		Sync:         d.Sync,
+	}
+	d.Directory = &super
+	return d
+}
+
+func (d *AsyncFlushDirectory) queueFor(name string) chan asyncWriteTask {
+	h := 0
+	for _, ch := range name {
+		h = 31*h + int(ch)
+	}
+	if h < 0 {
+		h = -h
+	}
+	return d.queues[h%d.numWriters]
+}
+
+func (d *AsyncFlushDirectory) createOutput(name string, context IOContext) (out *IndexOutput, err error) {
+	d.ensureOpen()
+	ao := &asyncIndexOutput{dir: d, name: name}
+	ao.BufferedIndexOutput = newBufferedIndexOutput(name)
+	ao.BufferedIndexOutput.flushBuffer = ao.enqueue
+	return ao.IndexOutput, nil
+}
+
+// asyncIndexOutput is a BufferedIndexOutput whose flushBuffer hands filled
+// buffers to the owning AsyncFlushDirectory instead of writing them inline.
+type asyncIndexOutput struct {
+	*BufferedIndexOutput
+	dir    *AsyncFlushDirectory
+	name   string
+	offset int64
+}
+
+func (ao *asyncIndexOutput) enqueue(b []byte) error {
+	d := ao.dir
+	buf := d.bufferPool.Get().([]byte)
+	buf = append(buf[:0], b...)
+
+	d.mu.Lock()
+	d.pending[ao.name]++
+	d.mu.Unlock()
+
+	d.queueFor(ao.name) <- asyncWriteTask{name: ao.name, buf: buf, offset: ao.offset}
+	ao.offset += int64(len(b))
+	return nil
+}
+
+func (d *AsyncFlushDirectory) drain(q chan asyncWriteTask) {
+	defer d.wg.Done()
+	for task := range q {
+		d.write(task)
+		d.bufferPool.Put(task.buf[:0])
+
+		d.mu.Lock()
+		d.pending[task.name]--
+		if d.pending[task.name] <= 0 {
+			delete(d.pending, task.name)
+		}
+		d.drained.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+func (d *AsyncFlushDirectory) write(task asyncWriteTask) {
+	// d.outputs is shared across every writer goroutine (each file is
+	// pinned to one goroutine by queueFor, but creating/looking up the
+	// entry for a *different* file still races against the other
+	// goroutines' map accesses), so the map itself needs d.mu even though
+	// the WriteByte loop below never runs concurrently for the same name.
+	d.mu.Lock()
+	out, ok := d.outputs[task.name]
+	d.mu.Unlock()
+	if !ok {
+		var err error
+		out, err = d.wrapped.CreateOutput(task.name, IO_CONTEXT_FLUSH)
+		if err != nil {
+			d.recordErr(err)
+			return
+		}
+		d.mu.Lock()
+		d.outputs[task.name] = out
+		d.mu.Unlock()
+	}
+	for _, b := range task.buf {
+		if err := out.WriteByte(b); err != nil {
+			d.recordErr(err)
+			return
+		}
+	}
+}
+
+func (d *AsyncFlushDirectory) recordErr(err error) {
+	d.errMu.Lock()
+	if d.firstErr == nil {
+		d.firstErr = err
+	}
+	d.errMu.Unlock()
+}
+
+func (d *AsyncFlushDirectory) awaitDrained(names []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range names {
+		for d.pending[name] > 0 {
+			d.drained.Wait()
+		}
+	}
+}
+
+// Sync blocks until every queued async write for names has drained, then
+// fsyncs them through the wrapped Directory.
+func (d *AsyncFlushDirectory) Sync(names []string) error {
+	d.awaitDrained(names)
+	if d.wrapped.Sync != nil {
+		return d.wrapped.Sync(names)
+	}
+	return nil
+}
+
+// Close blocks until every pending write across all files has drained,
+// closes the underlying outputs, and returns the first error observed by
+// any writer goroutine, if any. It is idempotent: a second call (e.g. a
+// deferred Close after an earlier explicit one on the success path) just
+// returns the same result instead of panicking on an already-closed queue.
+func (d *AsyncFlushDirectory) Close() error {
+	d.closeOnce.Do(func() {
+		for _, q := range d.queues {
+			close(q)
+		}
+		d.wg.Wait()
+
+		for _, out := range d.outputs {
+			if err := out.Close(); err != nil {
+				d.recordErr(err)
+			}
+		}
+
+		d.errMu.Lock()
+		d.closeErr = d.firstErr
+		d.errMu.Unlock()
+	})
+	return d.closeErr
+}
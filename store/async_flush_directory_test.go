@@ -0,0 +1,111 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// memTestDirectory is a minimal in-memory Directory standing in for a real
+// FSDirectory's write side (not part of this snapshot), just enough to give
+// AsyncFlushDirectory something to drain into for the race test below.
+type memTestDirectory struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemTestDirectory() (*Directory, *memTestDirectory) {
+	md := &memTestDirectory{data: make(map[string][]byte)}
+	d := &Directory{
+		CreateOutput: func(name string, context IOContext) (*IndexOutput, error) {
+			bo := newBufferedIndexOutput(name)
+			bo.flushBuffer = func(b []byte) error {
+				md.mu.Lock()
+				md.data[name] = append(md.data[name], b...)
+				md.mu.Unlock()
+				return nil
+			}
+			return bo.IndexOutput, nil
+		},
+	}
+	return d, md
+}
+
+// TestAsyncFlushDirectoryConcurrentWritersDoNotRaceOnOutputsMap writes to
+// more files than there are writer goroutines, concurrently, from the
+// caller side too - this is the shape (N files, M < N writer goroutines)
+// that previously triggered a concurrent map write on d.outputs under
+// `go test -race`.
+func TestAsyncFlushDirectoryConcurrentWritersDoNotRaceOnOutputsMap(t *testing.T) {
+	wrapped, md := newMemTestDirectory()
+	async := NewAsyncFlushDirectoryN(wrapped, 4)
+
+	const numFiles = 8
+	const perFileBytes = 50000
+
+	var wg sync.WaitGroup
+	expected := make(map[string][]byte, numFiles)
+	names := make([]string, 0, numFiles)
+	for i := 0; i < numFiles; i++ {
+		name := fmt.Sprintf("file%d", i)
+		names = append(names, name)
+
+		content := make([]byte, perFileBytes)
+		for j := range content {
+			content[j] = byte((i + j) % 251)
+		}
+		expected[name] = content
+
+		wg.Add(1)
+		go func(name string, content []byte) {
+			defer wg.Done()
+			out, err := async.CreateOutput(name, IO_CONTEXT_FLUSH)
+			if err != nil {
+				t.Errorf("CreateOutput(%v): %v", name, err)
+				return
+			}
+			for _, b := range content {
+				if err := out.WriteByte(b); err != nil {
+					t.Errorf("WriteByte(%v): %v", name, err)
+					return
+				}
+			}
+			if err := out.Close(); err != nil {
+				t.Errorf("Close(%v): %v", name, err)
+			}
+		}(name, content)
+	}
+	wg.Wait()
+
+	if err := async.Sync(names); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if err := async.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	for _, name := range names {
+		if !bytes.Equal(md.data[name], expected[name]) {
+			t.Fatalf("file %v: got %d bytes, want %d bytes (mismatch)", name, len(md.data[name]), len(expected[name]))
+		}
+	}
+}
+
+// TestAsyncFlushDirectoryCloseIsIdempotent guards against a second Close
+// call - a common defensive pattern, e.g. a deferred Close alongside an
+// earlier explicit one on the success path - panicking on an already-closed
+// queue channel.
+func TestAsyncFlushDirectoryCloseIsIdempotent(t *testing.T) {
+	wrapped, _ := newMemTestDirectory()
+	async := NewAsyncFlushDirectoryN(wrapped, 2)
+
+	if err := async.Close(); err != nil {
+		t.Fatalf("first Close: %v", err)
+	}
+	if err := async.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
@@ -18,7 +18,8 @@ const (
 type IOContextType int
 
 var (
-	IO_CONTEXT_READ = NewIOContextBool(false)
+	IO_CONTEXT_READ  = NewIOContextBool(false)
+	IO_CONTEXT_FLUSH = IOContext{context: IOContextType(IO_CONTEXT_TYPE_FLUSH)}
 )
 
 type IOContext struct {
@@ -49,11 +50,13 @@ type FSLockFactory struct {
 }
 
 type Directory struct {
-	isOpen      bool
-	lockFactory LockFactory
-	ListAll     func() (paths []string, err error)
-	OpenInput   func(name string, context IOContext) (in *IndexInput, err error)
-	LockID      func() string
+	isOpen       bool
+	lockFactory  LockFactory
+	ListAll      func() (paths []string, err error)
+	OpenInput    func(name string, context IOContext) (in *IndexInput, err error)
+	CreateOutput func(name string, context IOContext) (out *IndexOutput, err error)
+	Sync         func(names []string) error
+	LockID       func() string
 }
 
 func (d *Directory) SetLockFactory(lockFactory LockFactory) {
@@ -100,7 +103,7 @@ func newFSDirectory(path string) (d *FSDirectory, err error) {
 		}
 	}
 
-	super := Directory{ListAll: func() (paths []string, err error) {
+	super := Directory{isOpen: true, ListAll: func() (paths []string, err error) {
 		d.ensureOpen()
 		return ListAll(d.path)
 	}, LockID: func() string {
@@ -112,14 +115,19 @@ func newFSDirectory(path string) (d *FSDirectory, err error) {
 		return fmt.Sprintf("lucene-%v", strconv.FormatUint(uint64(digest), 10))
 	}}
 	d.Directory = &super
-	// TODO default to native lock factory
-	d.SetLockFactory(*(NewSimpleFSLockFactory(path).LockFactory))
+	if nativeLocksDisabled || !filesystemSupportsNativeLocks(path) {
+		d.SetLockFactory(*(NewSimpleFSLockFactory(path).LockFactory))
+	} else {
+		d.SetLockFactory(*(NewNativeFSLockFactory(path).LockFactory))
+	}
 	return d, nil
 }
 
-// TODO support lock factory
+// OpenFSDirectory opens an FSDirectory at path, defaulting to
+// NativeFSLockFactory. SimpleFSLockFactory is used instead if
+// DisableNativeLocks has been called, or if filesystemSupportsNativeLocks
+// reports that path's filesystem lacks working native locks.
 func OpenFSDirectory(path string) (d FSDirectory, err error) {
-	// TODO support native implementations
 	super, err := NewSimpleFSDirectory(path)
 	if err != nil {
 		return d, err
@@ -160,6 +168,13 @@ type IndexInput struct {
 	*DataInput
 	desc   string
 	Length func() int64
+	Close  func()
+	// Clone returns an independent copy of this IndexInput starting at its
+	// current read position, sharing the underlying file/mapping rather
+	// than reopening it, wherever the concrete type supports that (see
+	// MMapIndexInput.Clone and FSIndexInput.Clone). Nil if the concrete
+	// type behind this IndexInput doesn't wire it.
+	Clone func() *IndexInput
 }
 
 func newIndexInput(desc string) *IndexInput {
@@ -275,12 +290,70 @@ func newFSIndexInput(desc, path string, context IOContext, chunkSize int) (in *F
 	}
 	super := newBufferedIndexInput(desc, context)
 	in = &FSIndexInput{super, f, false, chunkSize, 0, fi.Size()}
+	in.wireBufferedInternals()
 	super.Length = func() int64 {
 		return in.end - in.off
 	}
+	super.Close = in.Close
+	super.DataInput.ReadByte = in.readByte
+	super.Clone = func() *IndexInput { return in.Clone().IndexInput }
 	return in, nil
 }
 
+// wireBufferedInternals points in's BufferedIndexInput at in.file via
+// ReadAt rather than Seek+Read: refill() only ever computes the absolute
+// read position (in.FilePointer(), valid at the point it calls
+// readInternal, before it moves bufferStart) and otherwise assumes the file's
+// position tracks it implicitly, which doesn't hold once a Clone (below)
+// shares the same *os.File - two cursors advancing independently would
+// stomp on each other's seek. ReadAt sidesteps that: it never touches the
+// shared file's position, so a master and its clones can read through the
+// same *os.File concurrently without corrupting one another.
+func (in *FSIndexInput) wireBufferedInternals() {
+	in.seekInternal = func(pos int64) {}
+	in.readInternal = func(b []byte, offset, length int) error {
+		_, err := in.file.ReadAt(b[offset:offset+length], in.off+in.FilePointer())
+		return err
+	}
+}
+
+// readByte backs the DataInput.ReadByte func field with
+// BufferedIndexInput.ReadByte (itself backed by seekInternal/readInternal,
+// wired above to the open os.File), which returns an error DataInput.ReadByte
+// has no room for; it panics on that error the same way
+// MMapIndexInput.readByte panics on a read past EOF.
+func (in *FSIndexInput) readByte() byte {
+	b, err := in.BufferedIndexInput.ReadByte()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Clone returns a new FSIndexInput sharing this input's open *os.File
+// instead of reopening the path, starting at this input's current read
+// position with its own independent cursor from there on - the same
+// contract MMapIndexInput.Clone documents. Close on the clone is a no-op;
+// only the master closes the shared file.
+func (in *FSIndexInput) Clone() *FSIndexInput {
+	super := newBufferedIndexInputBySize(in.desc, in.bufferSize)
+	clone := &FSIndexInput{super, in.file, true, in.chunkSize, in.off, in.end}
+	// Starting the clone's own bufferStart at the master's current
+	// position - rather than 0 - is what makes the clone's first read
+	// begin there, per the Clone contract MMapIndexInput.Clone documents;
+	// Length() below still reports the full resource length, matching it
+	// too.
+	super.bufferStart = in.FilePointer()
+	clone.wireBufferedInternals()
+	super.Length = func() int64 {
+		return clone.end - clone.off
+	}
+	super.Close = clone.Close
+	super.DataInput.ReadByte = clone.readByte
+	super.Clone = func() *IndexInput { return clone.Clone().IndexInput }
+	return clone
+}
+
 func (in *FSIndexInput) Close() {
 	// only close the file if this is not a clone
 	if !in.isClone {
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFSIndexInputReadsFileContent is a regression test for newFSIndexInput
+// leaving BufferedIndexInput's seekInternal/readInternal unset: without
+// them, refill (and therefore ReadByte) nil-panics on the very first read
+// of any FSDirectory-backed input.
+func TestFSIndexInputReadsFileContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-index-input-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("hello fs index input")
+	writeTempFile(t, dir, "seg1", content)
+
+	in, err := newFSIndexInput("test", filepath.Join(dir, "seg1"), IO_CONTEXT_READ, math.MaxInt32)
+	if err != nil {
+		t.Fatalf("newFSIndexInput: %v", err)
+	}
+	defer in.Close()
+
+	if got := in.Length(); got != int64(len(content)) {
+		t.Fatalf("Length() = %v, want %v", got, len(content))
+	}
+	for i, want := range content {
+		if got := in.readByte(); got != want {
+			t.Fatalf("byte %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+// TestFSIndexInputCloneSharesFileWithIndependentCursor mirrors
+// TestMMapIndexInputCloneStartsAtCurrentPosition: Clone must start at the
+// master's current position, advancing it must not move the master's own
+// cursor, and - since FSIndexInput.Clone shares the master's *os.File
+// rather than reopening it - the clone's reads must land at the right
+// absolute file offsets despite that sharing.
+func TestFSIndexInputCloneSharesFileWithIndependentCursor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-index-input-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("0123456789")
+	writeTempFile(t, dir, "seg1", content)
+
+	in, err := newFSIndexInput("test", filepath.Join(dir, "seg1"), IO_CONTEXT_READ, math.MaxInt32)
+	if err != nil {
+		t.Fatalf("newFSIndexInput: %v", err)
+	}
+	defer in.Close()
+
+	for i := 0; i < 4; i++ {
+		in.readByte()
+	}
+
+	clone := in.Clone()
+	if got := clone.Length(); got != int64(len(content)) {
+		t.Fatalf("clone.Length() = %v, want %v", got, len(content))
+	}
+	if got := clone.readByte(); got != content[4] {
+		t.Fatalf("clone's first byte = %v, want %v (clone should start at source's current position)", got, content[4])
+	}
+
+	if got := in.readByte(); got != content[4] {
+		t.Fatalf("source's next byte = %v, want %v (clone and source cursors must be independent)", got, content[4])
+	}
+
+	clone.Close() // no-op: clones never close the shared file
+	if got := in.readByte(); got != content[5] {
+		t.Fatalf("closing a clone must not close the master's shared file, got %v, want %v", got, content[5])
+	}
+}
+
+// TestFSIndexInputGenericCloneMatchesConcreteClone checks that the generic
+// IndexInput.Clone func field - the one store/fusemount actually calls,
+// since it only ever holds a *store.IndexInput - is wired to the same
+// behavior as the concrete FSIndexInput.Clone.
+func TestFSIndexInputGenericCloneMatchesConcreteClone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fs-index-input-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("hello generic clone")
+	writeTempFile(t, dir, "seg1", content)
+
+	in, err := newFSIndexInput("test", filepath.Join(dir, "seg1"), IO_CONTEXT_READ, math.MaxInt32)
+	if err != nil {
+		t.Fatalf("newFSIndexInput: %v", err)
+	}
+	defer in.Close()
+
+	if in.IndexInput.Clone == nil {
+		t.Fatalf("IndexInput.Clone was not wired by newFSIndexInput")
+	}
+	generic := in.IndexInput.Clone()
+	for i, want := range content {
+		if got := generic.ReadByte(); got != want {
+			t.Fatalf("byte %d = %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package store
+
+// FuseDirectory wraps an existing Directory (FSDirectory, RAMDirectory, or
+// any future implementation) so it can be exposed as a read-only FUSE
+// filesystem by the store/fusemount package. It does not sit in the
+// Directory hierarchy itself and adds no behavior of its own; it only
+// carries the Directory and mountpoint that the mount driver needs, keeping
+// store/fusemount (which wraps the actual FUSE library) out of this
+// package's import graph.
+type FuseDirectory struct {
+	Wrapped    *Directory
+	Mountpoint string
+}
+
+// NewFuseDirectory prepares wrapped to be mounted at mountpoint. Call
+// fusemount.Mount with the result to actually serve the filesystem.
+func NewFuseDirectory(wrapped *Directory, mountpoint string) *FuseDirectory {
+	return &FuseDirectory{Wrapped: wrapped, Mountpoint: mountpoint}
+}
@@ -0,0 +1,222 @@
+// +build fuse
+
+// Package fusemount mounts a store.FuseDirectory as a read-only FUSE
+// filesystem, so ops tools like grep, hexdump and xxd can inspect the
+// segment files of an index held in any Directory implementation -
+// including ones, like RAMDirectory or a future remote store, that don't
+// live on a real filesystem.
+//
+// Unlike the rest of this tree, this package depends on two packages this
+// repo doesn't vendor or pin - bazil.org/fuse and golang.org/x/net/context
+// - so it is built only under the "fuse" tag (go build/test -tags fuse)
+// to keep `go build ./...` working for everyone else without those
+// packages sitting in GOPATH. Run `go get bazil.org/fuse
+// golang.org/x/net/context` before building with the tag.
+package fusemount
+
+import (
+	"os"
+	"sync"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+
+	"github.com/balzaczyy/golucene/store"
+)
+
+// Mount represents an active FUSE mount of a Directory, rooted at
+// Mountpoint.
+type Mount struct {
+	conn       *fuse.Conn
+	Mountpoint string
+}
+
+// Mount mounts fd's wrapped Directory at fd.Mountpoint and serves requests
+// in a background goroutine until Close is called or the kernel tears the
+// mount down.
+func Mount(fd *store.FuseDirectory) (*Mount, error) {
+	conn, err := fuse.Mount(fd.Mountpoint, fuse.FSName("golucene"), fuse.Subtype("lucenefs"), fuse.ReadOnly())
+	if err != nil {
+		return nil, err
+	}
+	m := &Mount{conn: conn, Mountpoint: fd.Mountpoint}
+	go fs.Serve(conn, &root{dir: fd.Wrapped})
+	<-conn.Ready
+	if err := conn.MountError; err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Close unmounts the filesystem and closes the FUSE connection. It does
+// not wait for the serving goroutine to return.
+func (m *Mount) Close() error {
+	if err := fuse.Unmount(m.Mountpoint); err != nil {
+		return err
+	}
+	return m.conn.Close()
+}
+
+// root is the FUSE root directory node. It lists exactly the files the
+// wrapped Directory's ListAll reports, with no subdirectories.
+type root struct {
+	dir *store.Directory
+}
+
+func (r *root) Root() (fs.Node, error) {
+	return r, nil
+}
+
+func (r *root) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeDir | 0555
+	return nil
+}
+
+func (r *root) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	names, err := r.dir.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		if n == name {
+			return &segmentFile{dir: r.dir, name: name}, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (r *root) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	names, err := r.dir.ListAll()
+	if err != nil {
+		return nil, err
+	}
+	ents := make([]fuse.Dirent, len(names))
+	for i, n := range names {
+		ents[i] = fuse.Dirent{Name: n, Type: fuse.DT_File}
+	}
+	return ents, nil
+}
+
+// segmentFile is a single Lucene segment file exposed over FUSE. It keeps
+// one IndexInput open - the template - for as long as the file has been
+// looked up at all, and every Open/backward-seek gets its own cursor onto
+// the same file or mapping via template.Clone() instead of going back
+// through Directory.OpenInput. That's what turns a FUSE mount of an
+// MMapDirectory-backed index into one mmap per segment file rather than
+// one mmap per open handle, and is also why FSIndexInput itself learned to
+// Clone (share its *os.File) rather than reopen: this package's whole
+// reason to exist is mounting a big index cheaply, and re-opening on every
+// `cat`/`hexdump` call would defeat that.
+type segmentFile struct {
+	dir  *store.Directory
+	name string
+
+	mu       sync.Mutex
+	template *store.IndexInput
+}
+
+// templateInput returns f's template IndexInput, opening it on first use.
+// It is never advanced directly - only cloned - and stays open for as long
+// as the mount itself, since Lucene segment files are never modified once
+// written.
+func (f *segmentFile) templateInput() (*store.IndexInput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.template == nil {
+		in, err := f.dir.OpenInput(f.name, store.IO_CONTEXT_READ)
+		if err != nil {
+			return nil, err
+		}
+		f.template = in
+	}
+	return f.template, nil
+}
+
+func (f *segmentFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	in, err := f.templateInput()
+	if err != nil {
+		return err
+	}
+	a.Mode = 0444
+	a.Size = uint64(in.Length())
+	return nil
+}
+
+// Open clones f's template IndexInput for the lifetime of the handle,
+// instead of the node itself serving as a stateless handle:
+// segmentFileHandle.Read tracks how far that clone has been advanced so a
+// sequential scan (the cat/hexdump/xxd use case this package exists for)
+// only ever reads each byte once, rather than re-reading the file from
+// byte 0 on every FUSE read callback.
+func (f *segmentFile) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	tpl, err := f.templateInput()
+	if err != nil {
+		return nil, err
+	}
+	resp.Flags |= fuse.OpenKeepCache
+	return &segmentFileHandle{file: f, in: tpl.Clone()}, nil
+}
+
+// segmentFileHandle is the fs.Handle returned by segmentFile.Open. It keeps
+// one cloned IndexInput open for as long as the handle is, and remembers
+// how far that clone has read so far (pos), so monotonically increasing
+// offsets - the common case for a sequential scan - only ever advance
+// forward. A request for an offset behind pos (a real seek backwards)
+// clones the template again, since a clone has no way to rewind either,
+// rather than going back through Directory.OpenInput.
+type segmentFileHandle struct {
+	file *segmentFile
+	in   *store.IndexInput
+	pos  int64
+}
+
+// Read answers one FUSE read by advancing the handle's IndexInput clone to
+// req.Offset (re-cloning the template first if req.Offset is behind the
+// current position) and reading the requested span from there. A request
+// starting at or past EOF - which BufferedIndexInput.refill would report
+// as a "read past EOF" error - is answered with an empty read instead,
+// since that's what a FUSE caller expects there.
+func (h *segmentFileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	length := h.in.Length()
+	if req.Offset >= length {
+		return nil
+	}
+
+	if req.Offset < h.pos {
+		tpl, err := h.file.templateInput()
+		if err != nil {
+			return err
+		}
+		h.in = tpl.Clone()
+		h.pos = 0
+	}
+	for h.pos < req.Offset {
+		h.in.ReadByte()
+		h.pos++
+	}
+
+	size := req.Size
+	if req.Offset+int64(size) > length {
+		size = int(length - req.Offset)
+	}
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = h.in.ReadByte()
+	}
+	h.pos += int64(size)
+	resp.Data = buf
+	return nil
+}
+
+// Release closes the handle's IndexInput clone. For every concrete type in
+// this tree that's a no-op - only the shared template's own Close, never
+// called here, actually releases the file/mapping - but it's called
+// anyway in case a future Directory implementation's clones hold a
+// per-clone resource of their own.
+func (h *segmentFileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	if h.in.Close != nil {
+		h.in.Close()
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// +build fuse
+
+package fusemount
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bazil.org/fuse"
+	"golang.org/x/net/context"
+
+	"github.com/balzaczyy/golucene/store"
+)
+
+// TestSegmentFileDoesNotLeakIndexInputs guards against an fd leak across
+// repeated Attr/Open/Read/Release cycles on one segmentFile. Attr and Open
+// share segmentFile's one cached template IndexInput (Open hands out a
+// clone of it), so the whole loop holds a single os.File; Release closes
+// each clone, which for FSIndexInput is a no-op since clones share the
+// template's file. Run enough iterations to exceed a typical 1024 fd
+// ulimit, so that an os.File opened per iteration and never closed makes
+// this fail with "too many open files".
+func TestSegmentFileDoesNotLeakIndexInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fusemount-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("golucene segment contents")
+	if err := ioutil.WriteFile(filepath.Join(dir, "seg1"), content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	fsDir, err := store.OpenFSDirectory(dir)
+	if err != nil {
+		t.Fatalf("OpenFSDirectory: %v", err)
+	}
+	sf := &segmentFile{dir: fsDir.Directory, name: "seg1"}
+
+	for i := 0; i < 4000; i++ {
+		var a fuse.Attr
+		if err := sf.Attr(context.Background(), &a); err != nil {
+			t.Fatalf("Attr iteration %d: %v", i, err)
+		}
+		if a.Size != uint64(len(content)) {
+			t.Fatalf("Attr size = %v, want %v", a.Size, len(content))
+		}
+
+		h, err := sf.Open(context.Background(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+		if err != nil {
+			t.Fatalf("Open iteration %d: %v", i, err)
+		}
+		handle := h.(*segmentFileHandle)
+
+		req := &fuse.ReadRequest{Offset: 0, Size: len(content)}
+		resp := &fuse.ReadResponse{}
+		if err := handle.Read(context.Background(), req, resp); err != nil {
+			t.Fatalf("Read iteration %d: %v", i, err)
+		}
+		if string(resp.Data) != string(content) {
+			t.Fatalf("Read data = %q, want %q", resp.Data, content)
+		}
+		if err := handle.Release(context.Background(), &fuse.ReleaseRequest{}); err != nil {
+			t.Fatalf("Release iteration %d: %v", i, err)
+		}
+	}
+}
+
+// TestSegmentFileHandleSequentialReadsDoNotReopen checks that successive
+// in-order reads through the same handle advance the underlying IndexInput
+// instead of reopening it from byte 0 each time, by reading a file one byte
+// at a time and confirming every byte comes back correctly and in order.
+func TestSegmentFileHandleSequentialReadsDoNotReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fusemount-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("0123456789")
+	if err := ioutil.WriteFile(filepath.Join(dir, "seg1"), content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	fsDir, err := store.OpenFSDirectory(dir)
+	if err != nil {
+		t.Fatalf("OpenFSDirectory: %v", err)
+	}
+	sf := &segmentFile{dir: fsDir.Directory, name: "seg1"}
+
+	h, err := sf.Open(context.Background(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	handle := h.(*segmentFileHandle)
+	defer handle.Release(context.Background(), &fuse.ReleaseRequest{})
+
+	for i := range content {
+		req := &fuse.ReadRequest{Offset: int64(i), Size: 1}
+		resp := &fuse.ReadResponse{}
+		if err := handle.Read(context.Background(), req, resp); err != nil {
+			t.Fatalf("Read offset %d: %v", i, err)
+		}
+		if len(resp.Data) != 1 || resp.Data[0] != content[i] {
+			t.Fatalf("Read offset %d = %v, want %v", i, resp.Data, content[i:i+1])
+		}
+	}
+	if handle.pos != int64(len(content)) {
+		t.Fatalf("handle.pos = %v, want %v (should track forward progress)", handle.pos, len(content))
+	}
+}
+
+// TestSegmentFileOpenAndBackwardSeekReuseTemplateViaClone is a regression
+// test for segmentFile.Open and segmentFileHandle.Read going straight back
+// through Directory.OpenInput instead of cloning the cached template: that
+// would mean every FUSE open, and every backward seek, reopens the
+// underlying file (re-mmapping it from scratch for an MMapDirectory-backed
+// mount) instead of sharing the template's resources. It wraps
+// OpenFSDirectory's OpenInput to count calls and checks that Attr, two
+// Opens, and a backward seek within one of the resulting handles costs
+// exactly one underlying OpenInput call - the lazily-opened template - no
+// matter how many clones are handed out from it.
+func TestSegmentFileOpenAndBackwardSeekReuseTemplateViaClone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fusemount-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("0123456789")
+	if err := ioutil.WriteFile(filepath.Join(dir, "seg1"), content, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	fsDir, err := store.OpenFSDirectory(dir)
+	if err != nil {
+		t.Fatalf("OpenFSDirectory: %v", err)
+	}
+
+	opens := 0
+	counting := &store.Directory{
+		ListAll: fsDir.Directory.ListAll,
+		LockID:  fsDir.Directory.LockID,
+		OpenInput: func(name string, context store.IOContext) (*store.IndexInput, error) {
+			opens++
+			return fsDir.Directory.OpenInput(name, context)
+		},
+	}
+	sf := &segmentFile{dir: counting, name: "seg1"}
+
+	var a fuse.Attr
+	if err := sf.Attr(context.Background(), &a); err != nil {
+		t.Fatalf("Attr: %v", err)
+	}
+
+	h1, err := sf.Open(context.Background(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatalf("Open 1: %v", err)
+	}
+	h2, err := sf.Open(context.Background(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatalf("Open 2: %v", err)
+	}
+	handle1 := h1.(*segmentFileHandle)
+	handle2 := h2.(*segmentFileHandle)
+
+	// advance handle1 forward, then force a backward seek on it.
+	req := &fuse.ReadRequest{Offset: 5, Size: 1}
+	resp := &fuse.ReadResponse{}
+	if err := handle1.Read(context.Background(), req, resp); err != nil {
+		t.Fatalf("Read forward: %v", err)
+	}
+	req = &fuse.ReadRequest{Offset: 0, Size: 1}
+	resp = &fuse.ReadResponse{}
+	if err := handle1.Read(context.Background(), req, resp); err != nil {
+		t.Fatalf("Read backward: %v", err)
+	}
+	if resp.Data[0] != content[0] {
+		t.Fatalf("backward seek read = %v, want %v", resp.Data[0], content[0])
+	}
+
+	if opens != 1 {
+		t.Fatalf("underlying OpenInput calls = %v, want 1 (Attr/Open/backward-seek should all clone the cached template)", opens)
+	}
+
+	handle1.Release(context.Background(), &fuse.ReleaseRequest{})
+	handle2.Release(context.Background(), &fuse.ReleaseRequest{})
+}
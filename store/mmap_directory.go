@@ -0,0 +1,210 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DEFAULT_MAX_CHUNK_SIZE is the largest size, in bytes, that a single mmap'd
+// region will cover unless overridden via SetMaxChunkSize. It matches
+// Lucene's own default: 1<<30 on 64-bit JVMs/hosts, 1<<28 on 32-bit ones,
+// since mapping the whole (possibly huge) file in one region risks
+// exhausting the address space on 32-bit platforms.
+var DEFAULT_MAX_CHUNK_SIZE = func() int {
+	if strconv.IntSize == 64 {
+		return 1 << 30
+	}
+	return 1 << 28
+}()
+
+// MMapDirectory is a Directory implementation that reads index files by
+// mmap'ing them instead of going through BufferedIndexInput.refill. It
+// embeds FSDirectory for path handling, listing and locking, but replaces
+// its OpenInput, since its IndexInputs bypass the buffered-read machinery
+// entirely.
+type MMapDirectory struct {
+	*FSDirectory
+	chunkSizePower uint
+}
+
+// NewMMapDirectory opens path as an MMapDirectory, using DEFAULT_MAX_CHUNK_SIZE.
+func NewMMapDirectory(path string) (d *MMapDirectory, err error) {
+	fsd, err := newFSDirectory(path)
+	if err != nil {
+		return nil, err
+	}
+	d = &MMapDirectory{FSDirectory: fsd}
+	d.SetMaxChunkSize(DEFAULT_MAX_CHUNK_SIZE)
+
+	super := *d.Directory
+	super.OpenInput = func(name string, context IOContext) (in *IndexInput, err error) {
+		d.ensureOpen()
+		mmi, err := newMMapIndexInput(fmt.Sprintf("MMapIndexInput(path=\"%v\")", filepath.Join(d.path, name)),
+			filepath.Join(d.path, name), d.chunkSizePower)
+		if err != nil {
+			return nil, err
+		}
+		return mmi.IndexInput, nil
+	}
+	d.Directory = &super
+	return d, nil
+}
+
+// SetMaxChunkSize changes the size of the regions newly opened IndexInputs
+// are split into. It only affects inputs opened afterwards, never those
+// already mapped. maxChunkSize is rounded down to the nearest power of two,
+// matching the mmap implementation's requirement that chunk boundaries be
+// addressable with a simple shift/mask, then clamped up to at least
+// mmapAllocationGranularity (the system page size on Unix, 64 KiB on
+// Windows): the OS requires the offset of every mapped region to be a
+// multiple of it, and every chunk but the last starts at a multiple of the
+// chunk size.
+func (d *MMapDirectory) SetMaxChunkSize(maxChunkSize int) {
+	if maxChunkSize <= 0 {
+		panic(errors.New("maxChunkSize must be positive"))
+	}
+	var power uint
+	for shift := uint(1); shift < 63 && (int64(1)<<shift) <= int64(maxChunkSize); shift++ {
+		power = shift
+	}
+	if min := mmapMinChunkSizePower(); power < min {
+		power = min
+	}
+	d.chunkSizePower = power
+}
+
+// mmapMinChunkSizePower is the smallest chunkSizePower that keeps every
+// non-final chunk offset passed to mmapRegion/munmapRegion a multiple of
+// mmapAllocationGranularity (the system page size on Unix, but the
+// coarser 64 KiB view-offset alignment MapViewOfFile requires on Windows -
+// see mmap_windows.go), since that granularity is itself always a power of
+// two.
+func mmapMinChunkSizePower() uint {
+	granularity := mmapAllocationGranularity()
+	var power uint
+	for (int64(1) << power) < granularity {
+		power++
+	}
+	return power
+}
+
+// MMapIndexInput is a zero-copy IndexInput backed directly by one or more
+// mmap'd regions of a file. Unlike BufferedIndexInput it never copies bytes
+// into an intermediate buffer: ReadByte (and therefore ReadInt/ReadLong,
+// which are built on top of it) indexes straight into the mapped slices.
+// The actual mmap/munmap syscalls live in mmap_unix.go/mmap_windows.go.
+type MMapIndexInput struct {
+	*IndexInput
+	buffers        [][]byte
+	curBufIndex    int
+	curBuf         []byte
+	curBufPosition int
+	length         int64
+	isClone        bool
+}
+
+func newMMapIndexInput(desc, path string, chunkSizePower uint) (in *MMapIndexInput, err error) {
+	if min := mmapMinChunkSizePower(); chunkSizePower < min {
+		chunkSizePower = min
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	length := fi.Size()
+	chunkSize := int64(1) << chunkSizePower
+	nrChunks := 1
+	if length > 0 {
+		nrChunks = int((length-1)>>chunkSizePower) + 1
+	}
+
+	buffers := make([][]byte, nrChunks)
+	var bufOffset int64
+	for i := 0; i < nrChunks; i++ {
+		bufSize := chunkSize
+		if bufOffset+bufSize > length {
+			bufSize = length - bufOffset
+		}
+		// bufSize is 0 for a zero-length file (and only then): mmapRegion
+		// special-cases that instead of handing a zero-length request to
+		// the OS, which on Unix returns EINVAL.
+		buf, err := mmapRegion(f, bufOffset, int(bufSize))
+		if err != nil {
+			for _, mapped := range buffers[:i] {
+				munmapRegion(mapped)
+			}
+			return nil, err
+		}
+		buffers[i] = buf
+		bufOffset += bufSize
+	}
+
+	super := newIndexInput(desc)
+	in = &MMapIndexInput{IndexInput: super, buffers: buffers, length: length}
+	if len(buffers) > 0 {
+		in.curBuf = buffers[0]
+	}
+	super.Length = func() int64 { return in.length }
+	super.DataInput.ReadByte = in.readByte
+	super.Close = in.Close
+	super.Clone = func() *IndexInput { return in.Clone().IndexInput }
+	return in, nil
+}
+
+func (in *MMapIndexInput) readByte() byte {
+	for in.curBufPosition >= len(in.curBuf) {
+		in.curBufIndex++
+		if in.curBufIndex >= len(in.buffers) {
+			panic(errors.New(fmt.Sprintf("read past EOF: %v", in)))
+		}
+		in.curBuf = in.buffers[in.curBufIndex]
+		in.curBufPosition = 0
+	}
+	b := in.curBuf[in.curBufPosition]
+	in.curBufPosition++
+	return b
+}
+
+// Clone returns a new MMapIndexInput sharing this input's mappings without
+// duplicating them. Per the IndexInput.Clone contract, the clone starts at
+// the source's *current* read position, not at the start of the file, so
+// it has its own independent cursor from there on. Close on it is a no-op;
+// only the master unmaps the regions.
+func (in *MMapIndexInput) Clone() *MMapIndexInput {
+	clone := &MMapIndexInput{
+		IndexInput:     newIndexInput(in.desc),
+		buffers:        in.buffers,
+		curBufIndex:    in.curBufIndex,
+		curBuf:         in.curBuf,
+		curBufPosition: in.curBufPosition,
+		length:         in.length,
+		isClone:        true,
+	}
+	clone.IndexInput.Length = func() int64 { return clone.length }
+	clone.IndexInput.DataInput.ReadByte = clone.readByte
+	clone.IndexInput.Close = clone.Close
+	clone.IndexInput.Clone = func() *IndexInput { return clone.Clone().IndexInput }
+	return clone
+}
+
+// Close unmaps all regions. Clones share the master's mappings, so closing
+// a clone does nothing; only closing the master actually munmaps.
+func (in *MMapIndexInput) Close() {
+	if in.isClone || in.buffers == nil {
+		return
+	}
+	for _, buf := range in.buffers {
+		munmapRegion(buf)
+	}
+	in.buffers = nil
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, dir, name string, content []byte) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+		t.Fatalf("writeTempFile: %v", err)
+	}
+}
+
+func TestMMapIndexInputReadsFileContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("hello mmap world")
+	writeTempFile(t, dir, "seg1", content)
+
+	// chunkSizePower=2 (4-byte chunks) is below the page size, so
+	// newMMapIndexInput clamps it up internally; this just checks that the
+	// clamp doesn't disturb read correctness.
+	in, err := newMMapIndexInput("test", filepath.Join(dir, "seg1"), 2)
+	if err != nil {
+		t.Fatalf("newMMapIndexInput: %v", err)
+	}
+	defer in.Close()
+
+	if got := in.IndexInput.Length(); got != int64(len(content)) {
+		t.Fatalf("Length() = %v, want %v", got, len(content))
+	}
+	for i, want := range content {
+		if got := in.readByte(); got != want {
+			t.Fatalf("byte %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMMapIndexInputCloneStartsAtCurrentPosition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("0123456789")
+	writeTempFile(t, dir, "seg1", content)
+
+	// chunkSizePower=2 is below the page size and gets clamped up
+	// internally; the clamp is orthogonal to what this test covers
+	// (Clone's cursor handling).
+	in, err := newMMapIndexInput("test", filepath.Join(dir, "seg1"), 2)
+	if err != nil {
+		t.Fatalf("newMMapIndexInput: %v", err)
+	}
+	defer in.Close()
+
+	// advance past the first 4 bytes before cloning
+	for i := 0; i < 4; i++ {
+		in.readByte()
+	}
+
+	clone := in.Clone()
+	if got := clone.readByte(); got != content[4] {
+		t.Fatalf("clone's first byte = %v, want %v (clone should start at source's current position)", got, content[4])
+	}
+
+	// advancing the clone must not move the source's cursor
+	if got := in.readByte(); got != content[4] {
+		t.Fatalf("source's next byte = %v, want %v (clone and source cursors must be independent)", got, content[4])
+	}
+
+	clone.Close() // no-op: clones never unmap
+	if in.buffers == nil {
+		t.Fatalf("closing a clone must not unmap the master's regions")
+	}
+}
+
+func TestMMapIndexInputEmptyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTempFile(t, dir, "empty", nil)
+
+	in, err := newMMapIndexInput("test", filepath.Join(dir, "empty"), 20)
+	if err != nil {
+		t.Fatalf("newMMapIndexInput on empty file: %v", err)
+	}
+	defer in.Close()
+
+	if got := in.IndexInput.Length(); got != 0 {
+		t.Fatalf("Length() = %v, want 0", got)
+	}
+}
+
+// TestMMapIndexInputCrossesChunkBoundary exercises an actual multi-chunk
+// file at the real, page-aligned chunk size, checking that readByte
+// correctly advances from one mmap'd region into the next.
+func TestMMapIndexInputCrossesChunkBoundary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	power := mmapMinChunkSizePower()
+	chunkSize := 1 << power
+	content := make([]byte, chunkSize+16)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	writeTempFile(t, dir, "seg1", content)
+
+	in, err := newMMapIndexInput("test", filepath.Join(dir, "seg1"), power)
+	if err != nil {
+		t.Fatalf("newMMapIndexInput: %v", err)
+	}
+	defer in.Close()
+
+	if len(in.buffers) < 2 {
+		t.Fatalf("len(buffers) = %v, want at least 2 (file should span multiple chunks)", len(in.buffers))
+	}
+	for i, want := range content {
+		if got := in.readByte(); got != want {
+			t.Fatalf("byte %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+// TestMMapDirectorySetMaxChunkSizeClampsToPageSize checks that requesting a
+// chunk size smaller than the system page size doesn't leave chunkSizePower
+// set to something that would produce non-page-aligned mmap offsets.
+func TestMMapDirectorySetMaxChunkSizeClampsToPageSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d, err := NewMMapDirectory(dir)
+	if err != nil {
+		t.Fatalf("NewMMapDirectory: %v", err)
+	}
+
+	d.SetMaxChunkSize(1)
+	if min := mmapMinChunkSizePower(); d.chunkSizePower < min {
+		t.Fatalf("chunkSizePower = %v, want at least %v (page size power)", d.chunkSizePower, min)
+	}
+}
+
+func TestMMapIndexInputCloseUnmapsMaster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTempFile(t, dir, "seg1", []byte("some bytes"))
+
+	in, err := newMMapIndexInput("test", filepath.Join(dir, "seg1"), 20)
+	if err != nil {
+		t.Fatalf("newMMapIndexInput: %v", err)
+	}
+	in.Close()
+	if in.buffers != nil {
+		t.Fatalf("Close should clear buffers on the master")
+	}
+}
@@ -0,0 +1,32 @@
+// +build !windows
+
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapRegion maps the [offset, offset+length) span of f PROT_READ/MAP_SHARED.
+// A zero-length request (an empty segment file) is handled without a
+// syscall, since syscall.Mmap itself rejects a zero-length mapping with
+// EINVAL.
+func mmapRegion(f *os.File, offset int64, length int) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+	return syscall.Mmap(int(f.Fd()), offset, length, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapRegion(buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	return syscall.Munmap(buf)
+}
+
+// mmapAllocationGranularity is the smallest offset alignment mmap(2)
+// requires for a mapped region: the system page size.
+func mmapAllocationGranularity() int64 {
+	return int64(os.Getpagesize())
+}
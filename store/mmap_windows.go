@@ -0,0 +1,57 @@
+// +build windows
+
+package store
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+// mmapRegion maps the [offset, offset+length) span of f read-only via
+// CreateFileMapping/MapViewOfFile. A zero-length request (an empty segment
+// file) is handled without calling into the OS at all, mirroring the Unix
+// side's EINVAL special-case.
+func mmapRegion(f *os.File, offset int64, length int) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	end := uint64(offset) + uint64(length)
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY,
+		uint32(end>>32), uint32(end), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ, uint32(uint64(offset)>>32), uint32(offset), uintptr(length))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&buf))
+	header.Data = addr
+	header.Len = length
+	header.Cap = length
+	return buf, nil
+}
+
+func munmapRegion(buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	return syscall.UnmapViewOfFile((uintptr)(unsafe.Pointer(&buf[0])))
+}
+
+// mmapAllocationGranularity is the smallest offset alignment
+// MapViewOfFile/MapViewOfFileEx require for a mapped region. Unlike Unix's
+// mmap(2), which only requires page alignment, Windows requires every
+// view's file offset to be a multiple of SYSTEM_INFO.dwAllocationGranularity
+// - 64 KiB on every Windows version in current use - not the 4 KiB page
+// size, so this is hard-coded rather than derived from os.Getpagesize().
+func mmapAllocationGranularity() int64 {
+	return 64 * 1024
+}
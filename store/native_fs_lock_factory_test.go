@@ -0,0 +1,57 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNativeFSLockFactoryExcludesConcurrentLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-lock-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	factory := NewNativeFSLockFactory(dir)
+
+	first := factory.Make("write.lock")
+	ok, err := first.Obtain(time.Second)
+	if err != nil || !ok {
+		t.Fatalf("first.Obtain() = %v, %v; want true, nil", ok, err)
+	}
+
+	second := factory.Make("write.lock")
+	ok, err = second.Obtain(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("second.Obtain() returned an error: %v", err)
+	}
+	if ok {
+		t.Fatalf("second.Obtain() succeeded while first still held the lock")
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("first.Release(): %v", err)
+	}
+
+	ok, err = second.Obtain(time.Second)
+	if err != nil || !ok {
+		t.Fatalf("second.Obtain() after release = %v, %v; want true, nil", ok, err)
+	}
+	second.Release()
+}
+
+func TestFilesystemSupportsNativeLocksOnOrdinaryDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-lock-fs-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A plain temp directory is neither NFS nor FUSE, so native locks
+	// should be reported as supported there.
+	if !filesystemSupportsNativeLocks(dir) {
+		t.Fatalf("expected native locks to be supported on an ordinary directory")
+	}
+}
@@ -0,0 +1,25 @@
+// +build !windows
+
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile acquires a non-blocking exclusive flock on f, returning
+// (false, nil) if it is already held by another process.
+func lockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,50 @@
+// +build windows
+
+package store
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileExclusiveLock   = 0x2
+	lockfileFailImmediately = 0x1
+
+	// errnoLockViolation is ERROR_LOCK_VIOLATION (winerror.h). The standard
+	// syscall package doesn't define this on Windows (only
+	// golang.org/x/sys/windows does), so it's spelled out as a raw errno.
+	errnoLockViolation = 33
+)
+
+// lockFile acquires a non-blocking exclusive LockFileEx lock on f,
+// returning (false, nil) if it is already held by another process.
+func lockFile(f *os.File) (bool, error) {
+	var overlapped syscall.Overlapped
+	r, _, err := procLockFileEx.Call(f.Fd(),
+		uintptr(lockfileExclusiveLock|lockfileFailImmediately), 0, ^uintptr(0), ^uintptr(0),
+		uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoLockViolation {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, ^uintptr(0), ^uintptr(0), uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// +build linux
+
+package store
+
+import "syscall"
+
+// Magic numbers from linux/magic.h for filesystem types known to accept an
+// flock() call without actually providing the mutual exclusion
+// NativeFSLockFactory depends on.
+const (
+	nfsSuperMagic  = 0x6969
+	fuseSuperMagic = 0x65735546
+)
+
+// filesystemSupportsNativeLocks reports whether path sits on a filesystem
+// where flock() is known to work properly. NFSv2 and some FUSE mounts
+// accept the call but never actually exclude other processes, which would
+// make NativeFSLockFactory silently fail to protect an index. The check goes
+// by filesystem type alone, so every NFS and every FUSE mount is reported
+// unsupported and gets SimpleFSLockFactory instead.
+func filesystemSupportsNativeLocks(path string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		// Can't tell: err on the side of the always-correct
+		// SimpleFSLockFactory rather than assuming native locks work.
+		return false
+	}
+	switch int64(stat.Type) {
+	case nfsSuperMagic, fuseSuperMagic:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,13 @@
+// +build !windows,!linux
+
+package store
+
+// filesystemSupportsNativeLocks assumes native locks work everywhere on
+// these platforms: detecting NFS/FUSE mounts the way
+// native_fs_lock_support_linux.go does isn't portable across the BSDs and
+// Darwin's differently-shaped Statfs_t, and getting it wrong silently would
+// be worse than not detecting it at all. DisableNativeLocks remains the
+// manual escape hatch for a known-bad mount.
+func filesystemSupportsNativeLocks(path string) bool {
+	return true
+}
@@ -0,0 +1,10 @@
+// +build windows
+
+package store
+
+// filesystemSupportsNativeLocks is always true on Windows: LockFileEx works
+// reliably on the filesystems (NTFS, ReFS, SMB) Lucene indexes typically
+// live on there.
+func filesystemSupportsNativeLocks(path string) bool {
+	return true
+}
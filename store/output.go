@@ -0,0 +1,104 @@
+package store
+
+import (
+	"fmt"
+)
+
+// DEFAULT_OUTPUT_BUFFER_SIZE is the default size of the write-behind buffer
+// BufferedIndexOutput accumulates before handing a full buffer to
+// flushBuffer.
+const DEFAULT_OUTPUT_BUFFER_SIZE = 16384
+
+type DataOutput struct {
+	WriteByte func(b byte) error
+}
+
+func (out *DataOutput) WriteInt(i int) error {
+	if err := out.WriteByte(byte(i >> 24)); err != nil {
+		return err
+	}
+	if err := out.WriteByte(byte(i >> 16)); err != nil {
+		return err
+	}
+	if err := out.WriteByte(byte(i >> 8)); err != nil {
+		return err
+	}
+	return out.WriteByte(byte(i))
+}
+
+func (out *DataOutput) WriteLong(n int64) error {
+	if err := out.WriteInt(int(n >> 32)); err != nil {
+		return err
+	}
+	return out.WriteInt(int(n))
+}
+
+type IndexOutput struct {
+	*DataOutput
+	desc        string
+	FilePointer func() int64
+	Close       func() error
+}
+
+func newIndexOutput(desc string) *IndexOutput {
+	if desc == "" {
+		panic("resourceDescription must not be null")
+	}
+	return &IndexOutput{DataOutput: &DataOutput{}, desc: desc}
+}
+
+// BufferedIndexOutput is the write-side peer of BufferedIndexInput: it
+// accumulates written bytes into an in-memory buffer and hands the buffer
+// off to flushBuffer once full (or on Close), rather than making a syscall
+// per byte.
+type BufferedIndexOutput struct {
+	*IndexOutput
+	bufferSize     int
+	buffer         []byte
+	bufferPosition int
+	written        int64
+	flushBuffer    func(b []byte) error
+}
+
+func newBufferedIndexOutput(desc string) *BufferedIndexOutput {
+	return newBufferedIndexOutputBySize(desc, DEFAULT_OUTPUT_BUFFER_SIZE)
+}
+
+func newBufferedIndexOutputBySize(desc string, bufferSize int) *BufferedIndexOutput {
+	checkBufferSize(bufferSize)
+	super := newIndexOutput(desc)
+	out := &BufferedIndexOutput{IndexOutput: super, bufferSize: bufferSize, buffer: make([]byte, bufferSize)}
+	super.DataOutput.WriteByte = out.writeByte
+	super.FilePointer = func() int64 { return out.written + int64(out.bufferPosition) }
+	super.Close = out.close
+	return out
+}
+
+func (out *BufferedIndexOutput) writeByte(b byte) error {
+	if out.bufferPosition >= out.bufferSize {
+		if err := out.flush(); err != nil {
+			return err
+		}
+	}
+	out.buffer[out.bufferPosition] = b
+	out.bufferPosition++
+	return nil
+}
+
+func (out *BufferedIndexOutput) flush() error {
+	if out.bufferPosition == 0 {
+		return nil
+	}
+	err := out.flushBuffer(out.buffer[:out.bufferPosition])
+	out.written += int64(out.bufferPosition)
+	out.bufferPosition = 0
+	return err
+}
+
+func (out *BufferedIndexOutput) close() error {
+	return out.flush()
+}
+
+func (out *BufferedIndexOutput) String() string {
+	return fmt.Sprintf("BufferedIndexOutput(%v)", out.desc)
+}